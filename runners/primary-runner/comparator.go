@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Pluggable comparators.
+//
+// Comparator replaces the hard-coded byte-for-byte DiffReaders check with a
+// family of modes selectable via -mode, so that acceptable formatting
+// differences (trailing whitespace, token order within a line, float
+// rounding) don't get falsely flagged as a mismatch the way a raw byte diff
+// does.
+
+var (
+	flagMode = flag.String("mode", "bytes", "comparator mode: bytes, lines, tokens, numeric, json, unordered-lines")
+	flagEps  = flag.Float64("eps", 1e-6, "absolute/relative epsilon for -mode=numeric")
+)
+
+// Comparator compares two output streams and reports whether they differ.
+// detail, when non-empty, describes the first point of divergence (e.g.
+// "token 3: \"1.5\" vs \"1.6\"") and is only meaningful when different is true.
+type Comparator interface {
+	Compare(r1, r2 io.Reader) (different bool, detail string, err error)
+}
+
+// getComparator resolves -mode to a Comparator, falling back to bytes mode
+// on an unrecognized value.
+func getComparator(mode string) Comparator {
+	switch mode {
+	case "lines":
+		return linesComparator{}
+	case "tokens":
+		return tokensComparator{}
+	case "numeric":
+		return numericComparator{eps: *flagEps}
+	case "json":
+		return jsonComparator{}
+	case "unordered-lines":
+		return unorderedLinesComparator{}
+	default:
+		return bytesComparator{}
+	}
+}
+
+// bytesComparator is today's byte-for-byte comparison.
+type bytesComparator struct{}
+
+func (bytesComparator) Compare(r1, r2 io.Reader) (bool, string, error) {
+	different, err := DiffReaders(r1, r2)
+	return different, "", err
+}
+
+// linesComparator compares line-by-line after trimming trailing whitespace
+// and dropping blank lines, so trailing spaces and stray newlines at the
+// end of a program's output don't count as a mismatch.
+type linesComparator struct{}
+
+func (linesComparator) Compare(r1, r2 io.Reader) (bool, string, error) {
+	lines1, err := readNonBlankTrimmedLines(r1)
+	if err != nil {
+		return true, "", err
+	}
+	lines2, err := readNonBlankTrimmedLines(r2)
+	if err != nil {
+		return true, "", err
+	}
+	for i := 0; i < len(lines1) && i < len(lines2); i++ {
+		if lines1[i] != lines2[i] {
+			return true, fmt.Sprintf("line %d: %q vs %q", i+1, lines1[i], lines2[i]), nil
+		}
+	}
+	if len(lines1) != len(lines2) {
+		return true, fmt.Sprintf("line count %d vs %d", len(lines1), len(lines2)), nil
+	}
+	return false, "", nil
+}
+
+func readNonBlankTrimmedLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// tokensComparator compares the whitespace-separated token streams,
+// ignoring how that whitespace is arranged into lines.
+type tokensComparator struct{}
+
+func (tokensComparator) Compare(r1, r2 io.Reader) (bool, string, error) {
+	s1 := bufio.NewScanner(r1)
+	s1.Split(bufio.ScanWords)
+	s2 := bufio.NewScanner(r2)
+	s2.Split(bufio.ScanWords)
+
+	pos := 0
+	for {
+		more1 := s1.Scan()
+		more2 := s2.Scan()
+		if !more1 || !more2 {
+			if more1 != more2 {
+				return true, fmt.Sprintf("token %d: one side ran out first", pos+1), nil
+			}
+			break
+		}
+		pos++
+		t1, t2 := s1.Text(), s2.Text()
+		if t1 != t2 {
+			return true, fmt.Sprintf("token %d: %q vs %q", pos, t1, t2), nil
+		}
+	}
+	if err := s1.Err(); err != nil {
+		return true, "", err
+	}
+	if err := s2.Err(); err != nil {
+		return true, "", err
+	}
+	return false, "", nil
+}
+
+// numericComparator parses each token as a float64 and accepts it as equal
+// if it's within an absolute or relative epsilon of the other side, the
+// usual tolerance competitive-programming judges apply to floating output.
+type numericComparator struct {
+	eps float64
+}
+
+func (c numericComparator) Compare(r1, r2 io.Reader) (bool, string, error) {
+	s1 := bufio.NewScanner(r1)
+	s1.Split(bufio.ScanWords)
+	s2 := bufio.NewScanner(r2)
+	s2.Split(bufio.ScanWords)
+
+	pos := 0
+	for {
+		more1 := s1.Scan()
+		more2 := s2.Scan()
+		if !more1 || !more2 {
+			if more1 != more2 {
+				return true, fmt.Sprintf("token %d: one side ran out first", pos+1), nil
+			}
+			break
+		}
+		pos++
+		t1, t2 := s1.Text(), s2.Text()
+		f1, err1 := strconv.ParseFloat(t1, 64)
+		f2, err2 := strconv.ParseFloat(t2, 64)
+		if err1 != nil || err2 != nil {
+			if t1 != t2 {
+				return true, fmt.Sprintf("token %d: %q vs %q (not numeric)", pos, t1, t2), nil
+			}
+			continue
+		}
+		diff := math.Abs(f1 - f2)
+		tol := c.eps * math.Max(1.0, math.Max(math.Abs(f1), math.Abs(f2)))
+		if diff > tol {
+			return true, fmt.Sprintf("token %d: %v vs %v (diff %v > eps %v)", pos, f1, f2, diff, tol), nil
+		}
+	}
+	if err := s1.Err(); err != nil {
+		return true, "", err
+	}
+	if err := s2.Err(); err != nil {
+		return true, "", err
+	}
+	return false, "", nil
+}
+
+// jsonComparator decodes both streams as JSON and compares the resulting
+// values structurally, so key order and insignificant whitespace don't
+// matter.
+type jsonComparator struct{}
+
+func (jsonComparator) Compare(r1, r2 io.Reader) (bool, string, error) {
+	var v1, v2 interface{}
+	if err := json.NewDecoder(r1).Decode(&v1); err != nil {
+		return true, "", fmt.Errorf("decoding out1: %w", err)
+	}
+	if err := json.NewDecoder(r2).Decode(&v2); err != nil {
+		return true, "", fmt.Errorf("decoding out2: %w", err)
+	}
+	if !reflect.DeepEqual(v1, v2) {
+		return true, "JSON values differ", nil
+	}
+	return false, "", nil
+}
+
+// unorderedLinesComparator compares outputs as multisets of lines, for
+// problems where the order of emitted lines isn't part of the spec.
+type unorderedLinesComparator struct{}
+
+func (unorderedLinesComparator) Compare(r1, r2 io.Reader) (bool, string, error) {
+	counts1, err := lineMultiset(r1)
+	if err != nil {
+		return true, "", err
+	}
+	counts2, err := lineMultiset(r2)
+	if err != nil {
+		return true, "", err
+	}
+	for line, n := range counts1 {
+		if counts2[line] != n {
+			return true, fmt.Sprintf("line %q: %d vs %d occurrences", line, n, counts2[line]), nil
+		}
+	}
+	for line, n := range counts2 {
+		if counts1[line] != n {
+			return true, fmt.Sprintf("line %q: %d vs %d occurrences", line, counts1[line], n), nil
+		}
+	}
+	return false, "", nil
+}
+
+func lineMultiset(r io.Reader) (map[string]int, error) {
+	counts := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		counts[scanner.Text()]++
+	}
+	return counts, scanner.Err()
+}
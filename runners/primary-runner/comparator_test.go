@@ -0,0 +1,127 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBytesComparator(t *testing.T) {
+	cmp := bytesComparator{}
+	if diff, _, err := cmp.Compare(strings.NewReader("abc"), strings.NewReader("abc")); err != nil || diff {
+		t.Fatalf("identical byte streams should match, got diff=%v err=%v", diff, err)
+	}
+	if diff, _, err := cmp.Compare(strings.NewReader("abc"), strings.NewReader("abc ")); err != nil || !diff {
+		t.Fatalf("trailing space should count as a byte difference, got diff=%v err=%v", diff, err)
+	}
+}
+
+func TestLinesComparatorIgnoresTrailingWhitespaceAndBlankLines(t *testing.T) {
+	cmp := linesComparator{}
+	diff, _, err := cmp.Compare(
+		strings.NewReader("a \nb\n\nc\n"),
+		strings.NewReader("a\n\nb\nc  \n"),
+	)
+	if err != nil || diff {
+		t.Fatalf("lines comparator should ignore trailing whitespace and blank lines, got diff=%v err=%v", diff, err)
+	}
+
+	diff, detail, err := cmp.Compare(strings.NewReader("a\nb\n"), strings.NewReader("a\nc\n"))
+	if err != nil || !diff {
+		t.Fatalf("differing lines should be reported, got diff=%v err=%v", diff, err)
+	}
+	if detail == "" {
+		t.Fatalf("expected a non-empty detail describing the mismatch")
+	}
+}
+
+func TestTokensComparator(t *testing.T) {
+	cmp := tokensComparator{}
+	diff, _, err := cmp.Compare(strings.NewReader("1 2\n3"), strings.NewReader("1\n2 3"))
+	if err != nil || diff {
+		t.Fatalf("token stream should be insensitive to line breaks, got diff=%v err=%v", diff, err)
+	}
+
+	diff, detail, err := cmp.Compare(strings.NewReader("1 2 3"), strings.NewReader("1 9 3"))
+	if err != nil || !diff {
+		t.Fatalf("differing tokens should be reported, got diff=%v err=%v", diff, err)
+	}
+	if !strings.Contains(detail, "token 2") {
+		t.Fatalf("detail should name the first differing token position, got %q", detail)
+	}
+}
+
+func TestNumericComparatorToleratesEpsilon(t *testing.T) {
+	cmp := numericComparator{eps: 1e-3}
+	diff, _, err := cmp.Compare(strings.NewReader("1.0001 2.0"), strings.NewReader("1.0002 2.0"))
+	if err != nil || diff {
+		t.Fatalf("values within epsilon should match, got diff=%v err=%v", diff, err)
+	}
+
+	diff, detail, err := cmp.Compare(strings.NewReader("1.0 2.0"), strings.NewReader("1.0 5.0"))
+	if err != nil || !diff {
+		t.Fatalf("values outside epsilon should differ, got diff=%v err=%v", diff, err)
+	}
+	if detail == "" {
+		t.Fatalf("expected a non-empty detail describing the mismatch")
+	}
+}
+
+func TestNumericComparatorNonNumericTokensFallBackToEquality(t *testing.T) {
+	cmp := numericComparator{eps: 1e-6}
+	diff, _, err := cmp.Compare(strings.NewReader("yes"), strings.NewReader("yes"))
+	if err != nil || diff {
+		t.Fatalf("identical non-numeric tokens should match, got diff=%v err=%v", diff, err)
+	}
+	diff, _, err = cmp.Compare(strings.NewReader("yes"), strings.NewReader("no"))
+	if err != nil || !diff {
+		t.Fatalf("differing non-numeric tokens should differ, got diff=%v err=%v", diff, err)
+	}
+}
+
+func TestJSONComparatorIgnoresKeyOrderAndWhitespace(t *testing.T) {
+	cmp := jsonComparator{}
+	diff, _, err := cmp.Compare(
+		strings.NewReader(`{"a":1,"b":2}`),
+		strings.NewReader(`{ "b": 2, "a": 1 }`),
+	)
+	if err != nil || diff {
+		t.Fatalf("equivalent JSON with different key order should match, got diff=%v err=%v", diff, err)
+	}
+
+	diff, _, err = cmp.Compare(strings.NewReader(`{"a":1}`), strings.NewReader(`{"a":2}`))
+	if err != nil || !diff {
+		t.Fatalf("different JSON values should differ, got diff=%v err=%v", diff, err)
+	}
+}
+
+func TestUnorderedLinesComparator(t *testing.T) {
+	cmp := unorderedLinesComparator{}
+	diff, _, err := cmp.Compare(strings.NewReader("a\nb\nc\n"), strings.NewReader("c\na\nb\n"))
+	if err != nil || diff {
+		t.Fatalf("same multiset of lines in a different order should match, got diff=%v err=%v", diff, err)
+	}
+
+	diff, _, err = cmp.Compare(strings.NewReader("a\na\nb\n"), strings.NewReader("a\nb\nb\n"))
+	if err != nil || !diff {
+		t.Fatalf("different line multisets should differ, got diff=%v err=%v", diff, err)
+	}
+}
+
+func TestGetComparatorDispatch(t *testing.T) {
+	cases := map[string]Comparator{
+		"bytes":           bytesComparator{},
+		"lines":           linesComparator{},
+		"tokens":          tokensComparator{},
+		"json":            jsonComparator{},
+		"unordered-lines": unorderedLinesComparator{},
+		"nonsense":        bytesComparator{},
+	}
+	for mode, want := range cases {
+		if got := getComparator(mode); got != want {
+			t.Fatalf("getComparator(%q) = %T, want %T", mode, got, want)
+		}
+	}
+	if _, ok := getComparator("numeric").(numericComparator); !ok {
+		t.Fatalf("getComparator(\"numeric\") should return a numericComparator")
+	}
+}
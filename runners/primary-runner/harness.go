@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// =====================
+// Structured multi-run harness.
+//
+// With -n > 1 this turns the single-shot comparison above into a
+// TestRunner: it repeats the generator/prog1/prog2 comparison for -n
+// iterations (or until the first failure with -fail-fast), running
+// -parallel of them at a time. Each iteration gets its own tempdir so
+// concurrent runs never share input.txt/out1.txt/out2.txt, and a
+// per-iteration -timeout bounds how long a single run may take.
+
+var (
+	flagN        = flag.Int("n", 1, "number of iterations to run (use TestRunner mode when > 1)")
+	flagParallel = flag.Int("parallel", 1, "number of iterations to run concurrently")
+	flagTimeout  = flag.Duration("timeout", 10*time.Second, "per-iteration timeout")
+	flagSeed     = flag.Int64("seed", 1, "base seed passed to the generator as its first argument")
+	flagFailFast = flag.Bool("fail-fast", false, "stop scheduling new iterations after the first failure")
+	flagReport   = flag.String("report", "report", "base path (without extension) for the JSON/JUnit reports")
+)
+
+// IterationResult records the outcome of a single TestGroup iteration.
+type IterationResult struct {
+	Index      int    `json:"index"`
+	Seed       int64  `json:"seed"`
+	Dir        string `json:"dir"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"durationMs"`
+	Err        string `json:"error,omitempty"`
+}
+
+// TestGroup runs a batch of iterations with a bounded pool of workers,
+// collecting their results under a mutex. A sync.WaitGroup tracks worker
+// completion, the way the xdelta test harness coordinates its workers.
+type TestGroup struct {
+	genBinary, prog1Binary, prog2Binary string
+	timeout                             time.Duration
+	baseSeed                            int64
+	failFast                            bool
+
+	mu      sync.Mutex
+	results []IterationResult
+	failed  bool
+}
+
+func newTestGroup(genBinary, prog1Binary, prog2Binary string) *TestGroup {
+	return &TestGroup{
+		genBinary:   genBinary,
+		prog1Binary: prog1Binary,
+		prog2Binary: prog2Binary,
+		timeout:     *flagTimeout,
+		baseSeed:    *flagSeed,
+		failFast:    *flagFailFast,
+	}
+}
+
+func (g *TestGroup) shouldStop() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.failFast && g.failed
+}
+
+func (g *TestGroup) record(result IterationResult) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.results = append(g.results, result)
+	if result.Status != SUCCESS {
+		g.failed = true
+	}
+}
+
+// Run executes n iterations using up to parallel concurrent workers and
+// returns their results, ordered by completion (not necessarily by index).
+func (g *TestGroup) Run(n, parallel int) []IterationResult {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				seed := g.baseSeed + int64(idx)
+				dir, err := ioutil.TempDir("", fmt.Sprintf("runtest-%d-", idx))
+				if err != nil {
+					g.record(IterationResult{Index: idx, Seed: seed, Status: ERROR, Err: err.Error()})
+					continue
+				}
+				start := time.Now()
+				status, err := runIteration(dir, g.genBinary, g.prog1Binary, g.prog2Binary, seed, g.timeout)
+				result := IterationResult{
+					Index:      idx,
+					Seed:       seed,
+					Dir:        dir,
+					Status:     status,
+					DurationMs: time.Since(start).Milliseconds(),
+				}
+				if err != nil {
+					result.Err = err.Error()
+				}
+				g.record(result)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if g.shouldStop() {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return g.results
+}
+
+// runIteration runs one isolated generator/prog1/prog2 comparison inside
+// dir, under a per-run timeout and the process-wide -cpu-seconds/-mem-mb/
+// -max-output-bytes sandbox limits, and writes input.txt/out1.txt/out2.txt/
+// status.json/diff.txt (plus err1.txt/err2.txt for captured stderr) there.
+// The returned status is one of SUCCESS/WA/TLE/MLE/RE/OLE/ERROR, the same
+// mini-judge verdicts the single-run path produces.
+func runIteration(dir, genBinary, prog1Binary, prog2Binary string, seed int64, timeout time.Duration) (string, error) {
+	sandbox := newSandboxFromFlags()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	generator := exec.CommandContext(ctx, genBinary, fmt.Sprintf("%d", seed))
+	r, err := generator.StdoutPipe()
+	if err != nil {
+		return ERROR, err
+	}
+
+	prog1, err := sandbox.Command(ctx, prog1Binary)
+	if err != nil {
+		return ERROR, err
+	}
+	prog2, err := sandbox.Command(ctx, prog2Binary)
+	if err != nil {
+		return ERROR, err
+	}
+
+	var inputBuf, out1Buf, out2Buf, err1Buf, err2Buf bytes.Buffer
+	prog1.Stderr = &err1Buf
+	prog2.Stderr = &err2Buf
+
+	w1, r1, err := runProg(prog1)
+	if err != nil {
+		return ERROR, err
+	}
+	w2, r2, err := runProg(prog2)
+	if err != nil {
+		return ERROR, err
+	}
+
+	copyDone := make(chan struct{})
+	go func() {
+		defer close(copyDone)
+		defer w1.Close()
+		defer w2.Close()
+		io.Copy(io.MultiWriter(w1, w2, &inputBuf), r)
+	}()
+
+	if err := generator.Start(); err != nil {
+		return ERROR, err
+	}
+
+	killProg1 := func() {
+		if prog1.Process != nil {
+			prog1.Process.Kill()
+		}
+	}
+	killProg2 := func() {
+		if prog2.Process != nil {
+			prog2.Process.Kill()
+		}
+	}
+	bw1 := &boundedWriter{w: &out1Buf, limit: sandbox.MaxOutputBytes, kill: killProg1}
+	bw2 := &boundedWriter{w: &out2Buf, limit: sandbox.MaxOutputBytes, kill: killProg2}
+	tee1 := io.TeeReader(r1, bw1)
+	tee2 := io.TeeReader(r2, bw2)
+	different, detail, cmpErr := getComparator(*flagMode).Compare(tee1, tee2)
+
+	<-copyDone
+	genErr := generator.Wait()
+	err1 := prog1.Wait()
+	err2 := prog2.Wait()
+
+	if cmpErr != nil {
+		return ERROR, cmpErr
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "input.txt"), inputBuf.Bytes(), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "out1.txt"), out1Buf.Bytes(), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "out2.txt"), out2Buf.Bytes(), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "err1.txt"), err1Buf.Bytes(), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "err2.txt"), err2Buf.Bytes(), 0644)
+
+	status1 := sandbox.classify(ctx, err1, prog1.ProcessState, int64(out1Buf.Len()), different)
+	status2 := sandbox.classify(ctx, err2, prog2.ProcessState, int64(out2Buf.Len()), different)
+	status := worseStatus(status1, status2)
+
+	if status != SUCCESS {
+		diffText := unifiedDiff(out1Buf.String(), out2Buf.String(), *flagContext)
+		ioutil.WriteFile(filepath.Join(dir, "diff.txt"), []byte(diffText), 0644)
+	}
+	statusFields := map[string]interface{}{
+		"status": status,
+		"prog1":  usage(prog1.ProcessState),
+		"prog2":  usage(prog2.ProcessState),
+	}
+	if detail != "" {
+		statusFields["detail"] = detail
+	}
+	statusJson, err := json.Marshal(statusFields)
+	if err != nil {
+		return status, err
+	}
+	ioutil.WriteFile(filepath.Join(dir, "status.json"), statusJson, 0644)
+
+	if genErr != nil {
+		log.Printf("generator exited with error: %v", genErr)
+	}
+	return status, nil
+}
+
+// writeJSONReport writes the aggregated results as a JSON array.
+func writeJSONReport(path string, results []IterationResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// JUnit XML schema, kept to the subset CI systems actually parse.
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name    string        `xml:"name,attr"`
+	Time    float64       `xml:"time,attr"`
+	Failure *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitReport writes the aggregated results as a JUnit XML test suite.
+func writeJUnitReport(path string, results []IterationResult) error {
+	suite := junitTestSuite{Name: "runtest", Tests: len(results)}
+	for _, r := range results {
+		tc := junitTestCase{
+			Name: fmt.Sprintf("iteration-%d-seed-%d", r.Index, r.Seed),
+			Time: float64(r.DurationMs) / 1000.0,
+		}
+		if r.Status != SUCCESS {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: r.Status,
+				Text:    fmt.Sprintf("dir=%s err=%s", r.Dir, r.Err),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// runMultiple drives the TestGroup for -n iterations and writes the
+// aggregated JSON/JUnit reports; it's the entry point main uses when -n > 1.
+func runMultiple(genBinary, prog1Binary, prog2Binary string) {
+	group := newTestGroup(genBinary, prog1Binary, prog2Binary)
+	results := group.Run(*flagN, *flagParallel)
+
+	if err := writeJSONReport(*flagReport+".json", results); err != nil {
+		log.Fatal(err)
+	}
+	if err := writeJUnitReport(*flagReport+".xml", results); err != nil {
+		log.Fatal(err)
+	}
+
+	failures := 0
+	for _, r := range results {
+		if r.Status != SUCCESS {
+			failures++
+		}
+	}
+	log.Printf("ran %d iteration(s), %d failure(s)\n", len(results), failures)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeScript writes an executable shell script into dir/name and returns
+// its path, for use as a stand-in genBinary/prog1Binary/prog2Binary without
+// needing to compile a Go helper binary during go test.
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatalf("writing %s: %v", name, err)
+	}
+	return path
+}
+
+// TestTestGroupRunFailFast sets up a generator that emits the iteration's
+// seed and a prog2 that deliberately garbles its response on seed 2, then
+// checks that -fail-fast stops scheduling further iterations once that
+// mismatch is recorded.
+func TestTestGroupRunFailFast(t *testing.T) {
+	dir := t.TempDir()
+	gen := writeScript(t, dir, "gen.sh", `echo "seed=$1"
+`)
+	prog1 := writeScript(t, dir, "prog1.sh", `cat
+`)
+	prog2 := writeScript(t, dir, "prog2.sh", `read line
+if [ "$line" = "seed=2" ]; then
+  echo "seed=BAD"
+else
+  echo "$line"
+fi
+`)
+
+	oldSeed, oldFailFast, oldTimeout := *flagSeed, *flagFailFast, *flagTimeout
+	*flagSeed = 1
+	*flagFailFast = true
+	*flagTimeout = 5 * time.Second
+	defer func() {
+		*flagSeed, *flagFailFast, *flagTimeout = oldSeed, oldFailFast, oldTimeout
+	}()
+
+	group := newTestGroup(gen, prog1, prog2)
+	results := group.Run(8, 1)
+
+	// The scheduling loop only observes a failure once the worker has fully
+	// recorded it, which (with a single worker) is one send behind the
+	// in-flight job — so fail-fast stops short of n, not necessarily
+	// immediately after the failing iteration.
+	if len(results) >= 8 {
+		t.Fatalf("-fail-fast with parallel=1 should stop before exhausting all iterations, got %d results: %+v", len(results), results)
+	}
+	sawFailure := false
+	for _, r := range results {
+		if r.Status != SUCCESS {
+			sawFailure = true
+		}
+	}
+	if !sawFailure {
+		t.Fatalf("expected the seed=2 mismatch to be recorded among the results, got %+v", results)
+	}
+}
+
+// TestTestGroupRunParallelRunsAllIterations checks that without -fail-fast,
+// all n iterations run to completion even when several workers race to
+// pull jobs off the shared channel.
+func TestTestGroupRunParallelRunsAllIterations(t *testing.T) {
+	dir := t.TempDir()
+	gen := writeScript(t, dir, "gen.sh", `echo "seed=$1"
+`)
+	prog1 := writeScript(t, dir, "prog1.sh", `cat
+`)
+	prog2 := writeScript(t, dir, "prog2.sh", `cat
+`)
+
+	oldSeed, oldFailFast, oldTimeout := *flagSeed, *flagFailFast, *flagTimeout
+	*flagSeed = 1
+	*flagFailFast = false
+	*flagTimeout = 5 * time.Second
+	defer func() {
+		*flagSeed, *flagFailFast, *flagTimeout = oldSeed, oldFailFast, oldTimeout
+	}()
+
+	group := newTestGroup(gen, prog1, prog2)
+	results := group.Run(6, 3)
+
+	if len(results) != 6 {
+		t.Fatalf("expected all 6 iterations to run, got %d: %+v", len(results), results)
+	}
+	for _, r := range results {
+		if r.Status != SUCCESS {
+			t.Errorf("iteration %d (seed=%d) expected SUCCESS, got %q (err=%s)", r.Index, r.Seed, r.Status, r.Err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// =====================
+// Interactive-protocol mode.
+//
+// Instead of the generator producing its whole input up front, -interactive
+// treats it as a judge/interactor: it emits one line of stimulus at a time,
+// that line is forwarded to both prog1 and prog2, and their responses are
+// compared turn by turn. prog1's response is fed back into the generator's
+// stdin so it can decide the next stimulus, the same way a competitive
+// programming interactor drives a single candidate solution; prog2 rides
+// along as the other side of the comparison. Divergence is reported at the
+// exact turn it happens, and the whole exchange is recorded to
+// transcript.jsonl.
+
+var (
+	flagInteractive = flag.Bool("interactive", false, "treat the generator as a line-by-line interactor instead of a one-shot input producer")
+	flagTurnTimeout = flag.Duration("turn-timeout", 5*time.Second, "max time to wait for a single line in -interactive mode")
+	flagMaxTurns    = flag.Int("max-turns", 10000, "max number of turns before giving up in -interactive mode")
+)
+
+var errTurnTimeout = errors.New("timed out waiting for a line")
+
+// lineReader scans lines off r on a background goroutine and makes them
+// available with a timeout, so a turn can give up on a program that never
+// answers instead of blocking forever on bufio.Scanner.Scan.
+type lineReader struct {
+	lines chan string
+	err   chan error
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	lr := &lineReader{lines: make(chan string), err: make(chan error, 1)}
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			lr.lines <- scanner.Text()
+		}
+		close(lr.lines)
+		if err := scanner.Err(); err != nil {
+			lr.err <- err
+		} else {
+			lr.err <- io.EOF
+		}
+	}()
+	return lr
+}
+
+func (lr *lineReader) readLine(timeout time.Duration) (string, error) {
+	select {
+	case line, ok := <-lr.lines:
+		if !ok {
+			return "", <-lr.err
+		}
+		return line, nil
+	case <-time.After(timeout):
+		return "", errTurnTimeout
+	}
+}
+
+// turnRecord is one line of transcript.jsonl.
+type turnRecord struct {
+	Turn     int    `json:"turn"`
+	Sent     string `json:"sent"`
+	Resp1    string `json:"resp1"`
+	Resp2    string `json:"resp2"`
+	Err1     string `json:"err1,omitempty"`
+	Err2     string `json:"err2,omitempty"`
+	Diverged bool   `json:"diverged"`
+}
+
+// runInteractive drives the turn-by-turn exchange described above and
+// returns a status string (SUCCESS, FAILURE, or TLE on a turn timeout or
+// a max-turns exhaustion). The generator/prog1/prog2 all run under a
+// single context that gets canceled on the way out, which os/exec turns
+// into a Process.Kill — so a program that ignores a timed-out turn or a
+// closed stdin gets killed instead of leaving Wait blocked forever.
+func runInteractive(genBinary, prog1Binary, prog2Binary string) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	generator := exec.CommandContext(ctx, genBinary)
+	prog1 := exec.CommandContext(ctx, prog1Binary)
+	prog2 := exec.CommandContext(ctx, prog2Binary)
+
+	genOut, err := generator.StdoutPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	genIn, err := generator.StdinPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	p1In, err := prog1.StdinPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	p1Out, err := prog1.StdoutPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	p2In, err := prog2.StdinPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+	p2Out, err := prog2.StdoutPipe()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := generator.Start(); err != nil {
+		log.Fatal(err)
+	}
+	if err := prog1.Start(); err != nil {
+		log.Fatal(err)
+	}
+	if err := prog2.Start(); err != nil {
+		log.Fatal(err)
+	}
+
+	genReader := newLineReader(genOut)
+	p1Reader := newLineReader(p1Out)
+	p2Reader := newLineReader(p2Out)
+
+	transcript, err := os.Create("transcript.jsonl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer transcript.Close()
+	enc := json.NewEncoder(transcript)
+
+	status := SUCCESS
+	exhaustedTurns := true
+	for turn := 1; turn <= *flagMaxTurns; turn++ {
+		stimulus, err := genReader.readLine(*flagTurnTimeout)
+		if err == io.EOF {
+			exhaustedTurns = false
+			break
+		}
+		if err != nil {
+			status = TLE
+			exhaustedTurns = false
+			break
+		}
+
+		fmt.Fprintln(p1In, stimulus)
+		fmt.Fprintln(p2In, stimulus)
+
+		resp1, err1 := p1Reader.readLine(*flagTurnTimeout)
+		resp2, err2 := p2Reader.readLine(*flagTurnTimeout)
+
+		record := turnRecord{Turn: turn, Sent: stimulus, Resp1: resp1, Resp2: resp2}
+		if err1 != nil {
+			record.Err1 = err1.Error()
+		}
+		if err2 != nil {
+			record.Err2 = err2.Error()
+		}
+		record.Diverged = err1 != nil || err2 != nil || resp1 != resp2
+		if err := enc.Encode(record); err != nil {
+			log.Fatal(err)
+		}
+
+		if record.Diverged {
+			exhaustedTurns = false
+			if err1 == errTurnTimeout || err2 == errTurnTimeout {
+				status = TLE
+			} else {
+				status = FAILURE
+			}
+			break
+		}
+
+		fmt.Fprintln(genIn, resp1)
+	}
+
+	if exhaustedTurns {
+		status = TLE
+	}
+
+	genIn.Close()
+	p1In.Close()
+	p2In.Close()
+	cancel()
+	generator.Wait()
+	prog1.Wait()
+	prog2.Wait()
+
+	return status
+}
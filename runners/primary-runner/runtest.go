@@ -3,13 +3,16 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 )
 
 // =====================
@@ -116,6 +119,12 @@ func runProg(cmd *exec.Cmd) (io.WriteCloser, io.ReadCloser, error) {
 	return w, stdout, nil
 }
 
+var (
+	flagContext   = flag.Int("U", 3, "number of context lines around each hunk in diff.txt")
+	flagBrief     = flag.Bool("brief", false, "only report SUCCESS/FAILURE, skip generating diff.txt")
+	flagMinimizer = flag.Bool("minimizer", false, "on failure, shrink input.txt to a minimal failing case")
+)
+
 var inputLog, w1Log, w2Log bytes.Buffer
 
 func runIt(r io.Reader, prog1 *exec.Cmd, prog2 *exec.Cmd) (io.ReadCloser, io.ReadCloser) {
@@ -142,36 +151,79 @@ func runIt(r io.Reader, prog1 *exec.Cmd, prog2 *exec.Cmd) (io.ReadCloser, io.Rea
 }
 
 func main() {
-	genBinary, prog1Binary, prog2Binary := os.Args[1], os.Args[2], os.Args[3]
+	flag.Parse()
+	genBinary, prog1Binary, prog2Binary := flag.Arg(0), flag.Arg(1), flag.Arg(2)
+
+	if *flagN > 1 {
+		runMultiple(genBinary, prog1Binary, prog2Binary)
+		return
+	}
+
+	if *flagInteractive {
+		status := runInteractive(genBinary, prog1Binary, prog2Binary)
+		statusJson, err := json.Marshal(map[string]string{"status": status})
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s\n", statusJson)
+		if err := ioutil.WriteFile("status.json", statusJson, 0644); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	sandbox := newSandboxFromFlags()
+	ctx, cancel := context.WithTimeout(context.Background(), *flagTimeout)
+	defer cancel()
 
-	generator := exec.Command(genBinary)
+	generator := exec.CommandContext(ctx, genBinary)
 	r, err := generator.StdoutPipe()
 	if err != nil {
 		fmt.Println(err)
 	}
 
-	prog1 := exec.Command(prog1Binary)
-	prog2 := exec.Command(prog2Binary)
+	prog1, err := sandbox.Command(ctx, prog1Binary)
+	if err != nil {
+		log.Fatal(err)
+	}
+	prog2, err := sandbox.Command(ctx, prog2Binary)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	r1, r2 := runIt(r, prog1, prog2)
 
 	generator.Run()
 
-	status := PENDING
-	if areDifferent(r1, r2) {
-		status = FAILURE
-	} else {
-		status = SUCCESS
+	killProg1 := func() {
+		if prog1.Process != nil {
+			prog1.Process.Kill()
+		}
 	}
-	statusJson, err := json.Marshal(map[string]string{"status": status})
-	if err != nil {
-		log.Fatal(err)
+	killProg2 := func() {
+		if prog2.Process != nil {
+			prog2.Process.Kill()
+		}
 	}
+	different := areDifferent(r1, r2, sandbox.MaxOutputBytes, killProg1, killProg2)
 	err = prog1.Wait()
 	err1 := prog2.Wait()
 	if err != nil || err1 != nil {
 		fmt.Println(err, err1)
 	}
+
+	status1 := sandbox.classify(ctx, err, prog1.ProcessState, int64(w1Log.Len()), different)
+	status2 := sandbox.classify(ctx, err1, prog2.ProcessState, int64(w2Log.Len()), different)
+	status := worseStatus(status1, status2)
+
+	statusJson, err := json.Marshal(map[string]interface{}{
+		"status": status,
+		"prog1":  usage(prog1.ProcessState),
+		"prog2":  usage(prog2.ProcessState),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 	log.Printf("%s\n", statusJson)
 	log.Printf("%s\n", w1Log.Bytes())
 	log.Printf("%s\n", w2Log.Bytes())
@@ -180,27 +232,259 @@ func main() {
 	ioutil.WriteFile("out2.txt", w2Log.Bytes(), 0644)
 	ioutil.WriteFile("status.json", statusJson, 0644)
 
+	if status != SUCCESS && !*flagBrief {
+		diffText := unifiedDiff(w1Log.String(), w2Log.String(), *flagContext)
+		if err := ioutil.WriteFile("diff.txt", []byte(diffText), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if status != SUCCESS && *flagMinimizer {
+		minimized := minimizeInput(inputLog.Bytes(), prog1Binary, prog2Binary, getComparator(*flagMode))
+		if err := ioutil.WriteFile("input.txt", minimized, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	//fmt.Printf("inputLog: %s\n", &inputLog)
 	//fmt.Printf("w1Log: %s\n", &w1Log)
 	//fmt.Printf("w2Log: %s\n", &w2Log)
 }
 
-func areDifferent(r1, r2 io.Reader) bool {
-	same := false
+func areDifferent(r1, r2 io.Reader, maxOutputBytes int64, kill1, kill2 func()) bool {
 	iw1 := bufio.NewWriter(&w1Log)
 	iw2 := bufio.NewWriter(&w2Log)
 
 	defer iw1.Flush()
 	defer iw2.Flush()
 
-	tr1 := io.TeeReader(r1, iw1)
-	tr2 := io.TeeReader(r2, iw2)
+	bw1 := &boundedWriter{w: iw1, limit: maxOutputBytes, kill: kill1}
+	bw2 := &boundedWriter{w: iw2, limit: maxOutputBytes, kill: kill2}
+	tr1 := io.TeeReader(r1, bw1)
+	tr2 := io.TeeReader(r2, bw2)
 
-	same, err := DiffReaders(tr1, tr2)
+	different, detail, err := getComparator(*flagMode).Compare(tr1, tr2)
 	if err != nil {
 		log.Fatal(err)
 	}
-	return same
+	if different && detail != "" {
+		log.Printf("mismatch: %s\n", detail)
+	}
+	return different
+}
+
+// =====================
+// Unified diff (diff -u style) output.
+//
+// lcs runs the classic O(n*m) longest-common-subsequence DP over two line
+// slices and backtracks it into a sequence of edit ops. This is the
+// Hunt-McIlroy approach diff(1) is built on; a Myers O(ND) variant would
+// use less memory on huge inputs, but stress-test outputs are small enough
+// that the simple table is fine.
+type diffOp struct {
+	kind byte // '=' equal, '-' delete from a, '+' insert from b
+	a, b string
+}
+
+func lcsOps(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	dp := make([][]int, n+1)
+	for i := range dp {
+		dp[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else if dp[i+1][j] >= dp[i][j+1] {
+				dp[i][j] = dp[i+1][j]
+			} else {
+				dp[i][j] = dp[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{'=', a[i], b[j]})
+			i++
+			j++
+		case dp[i+1][j] >= dp[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i], ""})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', "", b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i], ""})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', "", b[j]})
+	}
+	return ops
+}
+
+// splitLines splits s on "\n" the way diff(1) treats a text file: a
+// trailing newline does not produce a trailing empty line.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// unifiedDiff renders a `diff -u`-style patch between out1 (treated as the
+// "a" side) and out2 (the "b" side), with context context lines of
+// unchanged text around each run of changes.
+func unifiedDiff(out1, out2 string, context int) string {
+	a := splitLines(out1)
+	b := splitLines(out2)
+	ops := lcsOps(a, b)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- out1.txt\n+++ out2.txt\n")
+
+	i := 0
+	for i < len(ops) {
+		if ops[i].kind == '=' {
+			i++
+			continue
+		}
+		// Found the start of a change; back up to include leading context.
+		start := i
+		for k := 0; k < context && start > 0 && ops[start-1].kind == '='; k++ {
+			start--
+		}
+
+		// Extend the hunk forward, absorbing any changes that are within
+		// 2*context lines of each other so they share one hunk.
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != '=' {
+				end++
+				continue
+			}
+			run := 0
+			for end+run < len(ops) && ops[end+run].kind == '=' {
+				run++
+			}
+			if run > 2*context || end+run >= len(ops) {
+				break
+			}
+			end += run
+		}
+		stop := end
+		for k := 0; k < context && stop < len(ops) && ops[stop].kind == '='; k++ {
+			stop++
+		}
+
+		aStart, bStart := 0, 0
+		for _, op := range ops[:start] {
+			if op.kind != '+' {
+				aStart++
+			}
+			if op.kind != '-' {
+				bStart++
+			}
+		}
+		aCount, bCount := 0, 0
+		for _, op := range ops[start:stop] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", aStart+1, aCount, bStart+1, bCount)
+		for _, op := range ops[start:stop] {
+			switch op.kind {
+			case '=':
+				fmt.Fprintf(&buf, " %s\n", op.a)
+			case '-':
+				fmt.Fprintf(&buf, "-%s\n", op.a)
+			case '+':
+				fmt.Fprintf(&buf, "+%s\n", op.b)
+			}
+		}
+
+		i = stop
+	}
+
+	return buf.String()
+}
+
+// =====================
+// Input minimizer: on failure, shrink the captured input down to a smaller
+// one that still reproduces the divergence, via interval halving
+// (delta-debugging's simplest case: ddmin with a fixed granularity of 2).
+
+// runPair feeds input to freshly started copies of prog1Binary and
+// prog2Binary and reports whether their outputs differ under cmp, the same
+// Comparator (-mode) the original run was invoked with.
+func runPair(input []byte, prog1Binary, prog2Binary string, cmp Comparator) (bool, error) {
+	prog1 := exec.Command(prog1Binary)
+	prog2 := exec.Command(prog2Binary)
+	prog1.Stdin = bytes.NewReader(input)
+	prog2.Stdin = bytes.NewReader(input)
+
+	var out1, out2 bytes.Buffer
+	prog1.Stdout = &out1
+	prog2.Stdout = &out2
+
+	if err := prog1.Start(); err != nil {
+		return false, err
+	}
+	if err := prog2.Start(); err != nil {
+		return false, err
+	}
+	err1 := prog1.Wait()
+	err2 := prog2.Wait()
+	if err1 != nil || err2 != nil {
+		return true, nil
+	}
+
+	different, _, err := cmp.Compare(bytes.NewReader(out1.Bytes()), bytes.NewReader(out2.Bytes()))
+	if err != nil {
+		return true, nil
+	}
+	return different, nil
+}
+
+// minimizeInput repeatedly halves the input's lines, keeping whichever half
+// still reproduces a divergence under cmp, until no half can be removed
+// without the failure disappearing.
+func minimizeInput(input []byte, prog1Binary, prog2Binary string, cmp Comparator) []byte {
+	lines := splitLines(string(input))
+	for {
+		if len(lines) <= 1 {
+			break
+		}
+		mid := len(lines) / 2
+		first := lines[:mid]
+		second := lines[mid:]
+
+		if differs, err := runPair([]byte(strings.Join(first, "\n")+"\n"), prog1Binary, prog2Binary, cmp); err == nil && differs {
+			lines = first
+			continue
+		}
+		if differs, err := runPair([]byte(strings.Join(second, "\n")+"\n"), prog1Binary, prog2Binary, cmp); err == nil && differs {
+			lines = second
+			continue
+		}
+		break
+	}
+	return []byte(strings.Join(lines, "\n") + "\n")
 }
 
 func diff2(r1, r2 io.Reader) bool {
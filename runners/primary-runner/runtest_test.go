@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"a\n", []string{"a"}},
+		{"a\nb\nc", []string{"a", "b", "c"}},
+		{"a\nb\n", []string{"a", "b"}},
+	}
+	for _, c := range cases {
+		got := splitLines(c.in)
+		if len(got) != len(c.want) {
+			t.Fatalf("splitLines(%q) = %v, want %v", c.in, got, c.want)
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Fatalf("splitLines(%q) = %v, want %v", c.in, got, c.want)
+			}
+		}
+	}
+}
+
+func TestLcsOpsIdentical(t *testing.T) {
+	a := []string{"x", "y", "z"}
+	ops := lcsOps(a, a)
+	for _, op := range ops {
+		if op.kind != '=' {
+			t.Fatalf("identical inputs should produce only '=' ops, got %v", ops)
+		}
+	}
+	if len(ops) != len(a) {
+		t.Fatalf("want %d equal ops, got %d", len(a), len(ops))
+	}
+}
+
+func TestLcsOpsSingleLineChange(t *testing.T) {
+	a := []string{"1", "2", "3"}
+	b := []string{"1", "9", "3"}
+	ops := lcsOps(a, b)
+
+	// "1" matches, "2" deleted, "9" inserted, "3" matches: exact op order can
+	// vary between equally-short edit scripts, so just check the counts.
+	var kinds []byte
+	for _, op := range ops {
+		kinds = append(kinds, op.kind)
+	}
+	var adds, dels, eqs int
+	for _, k := range kinds {
+		switch k {
+		case '+':
+			adds++
+		case '-':
+			dels++
+		case '=':
+			eqs++
+		}
+	}
+	if adds != 1 || dels != 1 || eqs != 2 {
+		t.Fatalf("lcsOps(%v, %v) = %v, want 1 insert, 1 delete, 2 equal", a, b, ops)
+	}
+}
+
+func TestUnifiedDiffNoChange(t *testing.T) {
+	out := unifiedDiff("a\nb\nc\n", "a\nb\nc\n", 3)
+	if strings.Contains(out, "@@") {
+		t.Fatalf("unifiedDiff with identical inputs should have no hunks, got %q", out)
+	}
+}
+
+func TestUnifiedDiffReportsHunk(t *testing.T) {
+	out := unifiedDiff("a\nb\nc\n", "a\nX\nc\n", 3)
+	if !strings.Contains(out, "@@") {
+		t.Fatalf("unifiedDiff should emit a hunk header, got %q", out)
+	}
+	if !strings.Contains(out, "-b") || !strings.Contains(out, "+X") {
+		t.Fatalf("unifiedDiff should show the removed/added lines, got %q", out)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// =====================
+// Resource-limited sandbox execution.
+//
+// Sandbox applies wall-clock (-timeout), CPU (-cpu-seconds) and memory
+// (-mem-mb) caps to prog1/prog2, and classifies how a run failed instead of
+// reporting a flat SUCCESS/FAILURE: TLE (timed out), MLE (hit the memory
+// cap), RE (nonzero exit or crash signal), OLE (produced too much output),
+// or WA (ran fine but disagreed with the other side). This makes the tool
+// usable as a mini judge for stress-testing solutions.
+//
+// os/exec has no direct way to setrlimit a child before it execs, so
+// CPU/memory limits are applied via a self re-exec trampoline: the parent
+// launches itself with RUNTEST_SANDBOX_EXEC=1, the trampoline calls
+// syscall.Setrlimit, and then syscall.Exec replaces its own image with the
+// real program. Because exec(2) keeps the same pid, cmd.ProcessState still
+// reports the real program's CPU time and peak RSS afterwards.
+
+const (
+	TLE = "TLE"
+	MLE = "MLE"
+	RE  = "RE"
+	OLE = "OLE"
+	WA  = "WA"
+)
+
+const (
+	sandboxExecEnv = "RUNTEST_SANDBOX_EXEC"
+	sandboxCPUEnv  = "RUNTEST_SANDBOX_CPU_SECONDS"
+	sandboxMemEnv  = "RUNTEST_SANDBOX_MEM_BYTES"
+)
+
+var (
+	flagCPUSeconds  = flag.Int("cpu-seconds", 0, "per-program CPU time limit in seconds (0 = unlimited)")
+	flagMemMB       = flag.Int("mem-mb", 0, "per-program address space limit in MB (0 = unlimited)")
+	flagMaxOutBytes = flag.Int64("max-output-bytes", 0, "kill a program once its output exceeds this many bytes (0 = unlimited)")
+)
+
+func init() {
+	if os.Getenv(sandboxExecEnv) == "1" {
+		sandboxExecChild()
+	}
+}
+
+// sandboxExecChild runs inside the re-exec trampoline: it applies the
+// requested rlimits and then replaces this process image with the real
+// target binary. It only returns (by exiting the process) on error.
+func sandboxExecChild() {
+	if s := os.Getenv(sandboxCPUEnv); s != "" {
+		if seconds, err := strconv.ParseUint(s, 10, 64); err == nil && seconds > 0 {
+			syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: seconds, Max: seconds})
+		}
+	}
+	if s := os.Getenv(sandboxMemEnv); s != "" {
+		if bytes, err := strconv.ParseUint(s, 10, 64); err == nil && bytes > 0 {
+			syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: bytes, Max: bytes})
+		}
+	}
+
+	target := os.Args[1]
+	args := os.Args[1:]
+	env := make([]string, 0, len(os.Environ()))
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, sandboxExecEnv+"=") ||
+			strings.HasPrefix(e, sandboxCPUEnv+"=") ||
+			strings.HasPrefix(e, sandboxMemEnv+"=") {
+			continue
+		}
+		env = append(env, e)
+	}
+	if err := syscall.Exec(target, args, env); err != nil {
+		fmt.Fprintln(os.Stderr, "sandbox: exec failed:", err)
+		os.Exit(127)
+	}
+}
+
+// Sandbox builds *exec.Cmd values that apply this process's configured
+// CPU/memory limits (if any) to the program they run, and classifies the
+// result of a run into a judge-style verdict.
+type Sandbox struct {
+	CPUSeconds     int
+	MemMB          int
+	MaxOutputBytes int64
+}
+
+func newSandboxFromFlags() Sandbox {
+	return Sandbox{
+		CPUSeconds:     *flagCPUSeconds,
+		MemMB:          *flagMemMB,
+		MaxOutputBytes: *flagMaxOutBytes,
+	}
+}
+
+// UsageStats summarizes a finished program's resource usage for status.json.
+type UsageStats struct {
+	CPUTimeMs int64 `json:"cpuTimeMs"`
+	PeakRSSKB int64 `json:"peakRssKb"`
+}
+
+// usage reads user CPU time and peak RSS out of a finished command's
+// ProcessState, as reported by the kernel via getrusage(2).
+func usage(ps *os.ProcessState) UsageStats {
+	if ps == nil {
+		return UsageStats{}
+	}
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return UsageStats{}
+	}
+	return UsageStats{
+		CPUTimeMs: ru.Utime.Sec*1000 + int64(ru.Utime.Usec)/1000,
+		PeakRSSKB: ru.Maxrss,
+	}
+}
+
+// boundedWriter kills an in-flight program as soon as its captured output
+// passes limit, so -max-output-bytes actually stops a runaway program
+// instead of only being noticed after it already ran to completion.
+type boundedWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+	kill    func()
+	tripped bool
+}
+
+func (b *boundedWriter) Write(p []byte) (int, error) {
+	n, err := b.w.Write(p)
+	b.written += int64(n)
+	if b.limit > 0 && b.written > b.limit && !b.tripped {
+		b.tripped = true
+		if b.kill != nil {
+			b.kill()
+		}
+	}
+	return n, err
+}
+
+// Command builds a command running binary with args under ctx, routed
+// through the re-exec trampoline when CPU or memory limits are configured,
+// and run directly otherwise.
+func (sb Sandbox) Command(ctx context.Context, binary string, args ...string) (*exec.Cmd, error) {
+	if sb.CPUSeconds == 0 && sb.MemMB == 0 {
+		return exec.CommandContext(ctx, binary, args...), nil
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs := append([]string{binary}, args...)
+	cmd := exec.CommandContext(ctx, self, cmdArgs...)
+	cmd.Env = append(os.Environ(),
+		sandboxExecEnv+"=1",
+		fmt.Sprintf("%s=%d", sandboxCPUEnv, sb.CPUSeconds),
+		fmt.Sprintf("%s=%d", sandboxMemEnv, sb.MemMB*1024*1024),
+	)
+	return cmd, nil
+}
+
+// classify turns one program's run outcome into a judge-style verdict.
+// waitErr is the error from cmd.Wait, ps its ProcessState, outputBytes how
+// much it wrote to stdout, and different whether its output diverged from
+// the other program's.
+func (sb Sandbox) classify(ctx context.Context, waitErr error, ps *os.ProcessState, outputBytes int64, different bool) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return TLE
+	}
+	if sb.MaxOutputBytes > 0 && outputBytes > sb.MaxOutputBytes {
+		return OLE
+	}
+	if waitErr != nil {
+		if ps != nil {
+			if status, ok := ps.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				switch status.Signal() {
+				case syscall.SIGXCPU:
+					return TLE
+				case syscall.SIGKILL:
+					if sb.CPUSeconds > 0 {
+						return TLE
+					}
+					if sb.MemMB > 0 {
+						return MLE
+					}
+				case syscall.SIGSEGV, syscall.SIGABRT, syscall.SIGBUS:
+					if sb.MemMB > 0 {
+						return MLE
+					}
+				}
+			}
+		}
+		return RE
+	}
+	if different {
+		return WA
+	}
+	return SUCCESS
+}
+
+// severity ranks judge verdicts so the worse of two can be kept: a clean
+// SUCCESS loses to a formatting mismatch (WA), which in turn loses to an
+// actual resource violation or crash (TLE/MLE/RE/OLE).
+func severity(status string) int {
+	switch status {
+	case SUCCESS:
+		return 0
+	case WA:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// worseStatus picks whichever of two verdicts is more severe, so that one
+// program timing out or crashing isn't masked by the other program merely
+// producing different (but otherwise fine) output.
+func worseStatus(a, b string) string {
+	if severity(b) > severity(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestWorseStatus(t *testing.T) {
+	cases := []struct {
+		a, b, want string
+	}{
+		{SUCCESS, SUCCESS, SUCCESS},
+		{SUCCESS, WA, WA},
+		{WA, SUCCESS, WA},
+		{WA, RE, RE},
+		{TLE, WA, TLE},
+		{MLE, TLE, MLE}, // same severity tier: keep the first
+	}
+	for _, c := range cases {
+		if got := worseStatus(c.a, c.b); got != c.want {
+			t.Errorf("worseStatus(%q, %q) = %q, want %q", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestClassifyTimeout(t *testing.T) {
+	sb := Sandbox{}
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Millisecond)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sleep", "1")
+	err := cmd.Run()
+	status := sb.classify(ctx, err, cmd.ProcessState, 0, false)
+	if status != TLE {
+		t.Fatalf("classify() under an expired context = %q, want TLE", status)
+	}
+}
+
+func TestClassifySuccessAndWA(t *testing.T) {
+	sb := Sandbox{}
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, "true")
+	err := cmd.Run()
+	if status := sb.classify(ctx, err, cmd.ProcessState, 0, false); status != SUCCESS {
+		t.Fatalf("classify() on a clean exit with matching output = %q, want SUCCESS", status)
+	}
+	if status := sb.classify(ctx, err, cmd.ProcessState, 0, true); status != WA {
+		t.Fatalf("classify() on a clean exit with diverging output = %q, want WA", status)
+	}
+}
+
+func TestClassifyNonzeroExitIsRE(t *testing.T) {
+	sb := Sandbox{}
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, "false")
+	err := cmd.Run()
+	if status := sb.classify(ctx, err, cmd.ProcessState, 0, false); status != RE {
+		t.Fatalf("classify() on a nonzero exit = %q, want RE", status)
+	}
+}
+
+func TestClassifyOutputLimitIsOLE(t *testing.T) {
+	sb := Sandbox{MaxOutputBytes: 10}
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, "true")
+	err := cmd.Run()
+	if status := sb.classify(ctx, err, cmd.ProcessState, 11, false); status != OLE {
+		t.Fatalf("classify() over the output byte cap = %q, want OLE", status)
+	}
+}
+
+func TestClassifyCrashSignalIsMLEUnderMemLimit(t *testing.T) {
+	sb := Sandbox{MemMB: 10}
+	ctx := context.Background()
+	cmd := exec.CommandContext(ctx, "sh", "-c", "kill -SEGV $$")
+	err := cmd.Run()
+	if status := sb.classify(ctx, err, cmd.ProcessState, 0, false); status != MLE {
+		t.Fatalf("classify() on a SIGSEGV with -mem-mb set = %q, want MLE", status)
+	}
+}